@@ -0,0 +1,79 @@
+package mfa
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sync"
+)
+
+//Clipboard is a Writer that copies the current OTP to the system clipboard, but only when asked - via
+//Copy(), armed by SIGUSR1 where the platform supports it - rather than on every tick, so it doesn't
+//clobber whatever the user copied a moment ago.
+type Clipboard struct {
+	mu     sync.Mutex
+	latest Event
+}
+
+//NewClipboard creates a Clipboard Writer & (where supported) arms a signal to trigger a copy of the
+//most recently written code
+func NewClipboard() *Clipboard {
+	c := &Clipboard{}
+	armClipboardSignal(c)
+	return c
+}
+
+//Write records the latest code so it's ready the next time Copy is triggered
+func (c *Clipboard) Write(e Event) error {
+	c.mu.Lock()
+	c.latest = e
+	c.mu.Unlock()
+	return nil
+}
+
+//Warn also just records the latest code - an OTP close to expiry is still worth copying
+func (c *Clipboard) Warn(e Event) error {
+	return c.Write(e)
+}
+
+//Error is a no-op - there's no code to copy when generation failed
+func (c *Clipboard) Error(e Event) error {
+	return nil
+}
+
+//Copy copies the most recently written code to the system clipboard
+func (c *Clipboard) Copy() error {
+	c.mu.Lock()
+	evt := c.latest
+	c.mu.Unlock()
+	if evt.Message == "" {
+		return nil
+	}
+	return copyToClipboard(evt.Message)
+}
+
+//copyToClipboard shells out to the platform's clipboard utility - there's no portable stdlib API for this
+func copyToClipboard(s string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("pbcopy")
+	case "windows":
+		cmd = exec.Command("clip")
+	default:
+		cmd = exec.Command("xclip", "-selection", "clipboard")
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprint(stdin, s); err != nil {
+		return err
+	}
+	stdin.Close()
+	return cmd.Wait()
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package mfa
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+//armClipboardSignal copies the latest code to the clipboard whenever the process receives SIGUSR1, rather
+//than on every tick, so a user can request a copy without clobbering the clipboard constantly.
+func armClipboardSignal(c *Clipboard) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		for range signals {
+			if err := c.Copy(); err != nil {
+				os.Stderr.WriteString("mfa: copying to clipboard: " + err.Error() + "\n")
+			}
+		}
+	}()
+}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package mfa
+
+//armClipboardSignal is a no-op on Windows - there's no SIGUSR1 equivalent, so callers must invoke Copy
+//directly (e.g. from a hotkey handler) to trigger a clipboard update.
+func armClipboardSignal(c *Clipboard) {}
@@ -1,15 +1,31 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/pquerna/otp"
 	"github.com/rbrumby/mfa"
+	"github.com/rbrumby/mfa/vault"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "vault" {
+		vaultMain(os.Args[2:])
+		return
+	}
+	mfaMain()
+}
+
+//mfaMain is the default behaviour - print an OTP, refreshing it until killed
+func mfaMain() {
 	flag.Usage = func() {
 		fmt.Fprintf(
 			os.Stderr,
@@ -32,6 +48,11 @@ func main() {
 	defaultColor := flag.String("color", "", "Terminal text color for default output. Valid colors are red, green, yellow, blue, purple, cyan, gray & white")
 	warningColor := flag.String("warn-color", "", "Terminal text color for warning output (when the OTP is close to expiry)")
 	errorColor := flag.String("error-color", "", "Terminal text color for outputting errors")
+	mode := flag.String("mode", "totp", "the OTP mode to use - totp (time-based, default) or hotp (counter-based)")
+	otpauthURL := flag.String("otpauth-url", "", "an otpauth:// provisioning URI - takes precedence over -secret/-secret-file/-mode/-algorithm/-digits/-refresh-period")
+	vaultFile := flag.String("vault-file", "", "an encrypted vault file (see 'mfa vault') to read the secret from")
+	vaultName := flag.String("vault-name", "", "the name of the vault entry to read from -vault-file")
+	output := flag.String("output", "terminal", "where to send OTP codes: terminal (default), clipboard, or socket:<path-or-host:port>")
 	flag.Parse()
 
 	term := mfa.NewTerminal(
@@ -41,46 +62,213 @@ func main() {
 		mfa.ErrorColor(mfa.TerminalColors[*errorColor]),
 	)
 
-	device := mfa.NewMFADevice(
-		mfa.Output(term),
-		mfa.Algorithm(*algorithm),
-		mfa.RefreshPeriod(*period),
-		mfa.Digits(*digits),
-		mfa.UpdateFrequency(time.Second*time.Duration(*frequency)),
-	)
+	writer, err := outputWriter(*output, term)
+	if err != nil {
+		fail(term, err)
+	}
 
-	switch {
-	case *secret != "":
-		//Use the secret if one is passed
-		mfa.Secret(*secret)(device)
-	case *secretFileName != "":
-		//Otherwise use secret-file if one is passed
-		secFile, err := os.Open(*secretFileName)
-		if err != nil {
-			panic(err)
+	deviceOpts := []func(*mfa.MFADevice){
+		mfa.Output(writer),
+		mfa.UpdateFrequency(time.Second * time.Duration(*frequency)),
+	}
+	if *otpauthURL != "" {
+		deviceOpts = append(deviceOpts, mfa.FromOTPAuthURL(*otpauthURL))
+	} else {
+		deviceOpts = append(deviceOpts,
+			mfa.Mode(*mode),
+			mfa.Algorithm(*algorithm),
+			mfa.RefreshPeriod(*period),
+			mfa.Digits(*digits),
+		)
+	}
+
+	device, err := mfa.NewMFADevice(deviceOpts...)
+	if err != nil {
+		fail(term, err)
+	}
+
+	if *otpauthURL == "" {
+		switch {
+		case *secret != "":
+			//Use the secret if one is passed
+			mfa.Secret(*secret)(device)
+		case *vaultFile != "":
+			//Otherwise use a vault entry if one is passed
+			mfa.SecretFromVault(*vaultFile, *vaultName, readVaultPassphrase)(device)
+		case *secretFileName != "":
+			//Otherwise use secret-file if one is passed
+			secFile, err := os.Open(*secretFileName)
+			if err != nil {
+				fail(term, err)
+			}
+			mfa.SecretFromFile(secFile)(device)
+		case os.Getenv(mfa.SecretFileNameEnv) != "":
+			//Otherwise use MFA_SECRET_FILE environment variable if it is set
+			env := os.Getenv(mfa.SecretFileNameEnv)
+			secFile, err := os.Open(env)
+			if err != nil {
+				fail(term, err)
+			}
+			mfa.SecretFromFile(secFile)(device)
+		default:
+			//Otherwise default to $HOME/.mfa/secret
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				fail(term, err)
+			}
+			secretFName := homeDir + "/.mfa/secret"
+			secFile, err := os.Open(secretFName)
+			if err != nil {
+				fail(term, err)
+			}
+			mfa.SecretFromFile(secFile)(device)
+		}
+		if device.Err() != nil {
+			fail(term, device.Err())
 		}
-		mfa.SecretFromFile(secFile)(device)
-	case os.Getenv(mfa.SecretFileNameEnv) != "":
-		//Otherwise use MFA_SECRET_FILE environment variable if it is set
-		env := os.Getenv(mfa.SecretFileNameEnv)
-		secFile, err := os.Open(env)
+	}
+
+	if device.Mode == mfa.ModeHOTP {
+		//HOTP codes shouldn't be generated on a timer - just print the next one & persist the counter
+		code, err := device.Next()
 		if err != nil {
-			panic(err)
+			fail(term, err)
 		}
-		mfa.SecretFromFile(secFile)(device)
+		writer.Write(mfa.Event{Time: time.Now(), Message: code})
+		return
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if err := device.Run(ctx); err != nil {
+		fail(term, err)
+	}
+}
+
+//fail reports err via term in ErrorColor & exits non-zero, rather than unwinding with a panic stack trace
+func fail(term *mfa.Terminal, err error) {
+	term.Error(mfa.Event{Time: time.Now(), Message: err.Error()})
+	os.Exit(1)
+}
+
+//outputWriter resolves the -output flag to a Writer: the given Terminal by default, or a Clipboard /
+//SocketServer for "clipboard" / "socket:<path-or-host:port>"
+func outputWriter(output string, term *mfa.Terminal) (mfa.Writer, error) {
+	switch {
+	case output == "" || output == "terminal":
+		return term, nil
+	case output == "clipboard":
+		return mfa.NewClipboard(), nil
+	case strings.HasPrefix(output, "socket:"):
+		return mfa.NewSocketServer(strings.TrimPrefix(output, "socket:"))
 	default:
-		//Otherwise default to $HOME/.totp/secret
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			panic(err)
+		return nil, fmt.Errorf("unknown -output %q", output)
+	}
+}
+
+//vaultMain handles the "mfa vault add|list|remove" subcommands for managing an encrypted secret store
+func vaultMain(args []string) {
+	term := mfa.NewTerminal()
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "usage: mfa vault <add|list|remove> [options]")
+		os.Exit(2)
+	}
+	sub := args[0]
+
+	fs := flag.NewFlagSet("vault "+sub, flag.ExitOnError)
+	vaultFile := fs.String("vault-file", defaultVaultFile(term), "the vault file to operate on")
+	name := fs.String("name", "", "the name of the entry to operate on")
+	secret := fs.String("secret", "", "the OTP secret to store (add only)")
+	secretFileName := fs.String("secret-file", "", "a file containing the OTP secret to store (add only)")
+	otpauthURL := fs.String("otpauth-url", "", "an otpauth:// provisioning URI to store (add only)")
+	fs.Parse(args[1:])
+
+	pass := cachingPassphrase(readVaultPassphrase)
+	v, err := vault.Open(*vaultFile, pass)
+	if err != nil {
+		if sub != "add" {
+			fail(term, err)
 		}
-		secretFName := homeDir + "/.mfa/secret"
-		secFile, err := os.Open(secretFName)
-		if err != nil {
-			panic(err)
+		v = vault.New(*vaultFile)
+	}
+
+	switch sub {
+	case "add":
+		if *name == "" {
+			fail(term, fmt.Errorf("-name is required"))
+		}
+		var secretBytes []byte
+		switch {
+		case *secret != "":
+			secretBytes = []byte(*secret)
+		case *secretFileName != "":
+			raw, err := ioutil.ReadFile(*secretFileName)
+			if err != nil {
+				fail(term, err)
+			}
+			secretBytes = raw
+		case *otpauthURL != "":
+			//Store just the extracted secret, not the raw URL - retrieval base32-decodes the stored
+			//bytes straight into totp/hotp.GenerateCodeCustom, which a whole otpauth:// URL isn't
+			key, err := otp.NewKeyFromURL(*otpauthURL)
+			if err != nil {
+				fail(term, fmt.Errorf("parsing -otpauth-url: %w", err))
+			}
+			secretBytes = []byte(key.Secret())
+		default:
+			fail(term, fmt.Errorf("one of -secret, -secret-file or -otpauth-url is required"))
+		}
+		v.Add(*name, secretBytes)
+		if err := v.Save(pass); err != nil {
+			fail(term, err)
 		}
-		mfa.SecretFromFile(secFile)(device)
+		fmt.Printf("added %q to %s\n", *name, *vaultFile)
+	case "list":
+		for _, n := range v.List() {
+			fmt.Println(n)
+		}
+	case "remove":
+		if *name == "" {
+			fail(term, fmt.Errorf("-name is required"))
+		}
+		v.Remove(*name)
+		if err := v.Save(pass); err != nil {
+			fail(term, err)
+		}
+		fmt.Printf("removed %q from %s\n", *name, *vaultFile)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown vault subcommand %q\n", sub)
+		os.Exit(2)
+	}
+}
+
+//defaultVaultFile is where the vault subcommands & -vault-file look by default
+func defaultVaultFile(term *mfa.Terminal) string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		fail(term, err)
 	}
+	return homeDir + "/.mfa/vault"
+}
+
+//readVaultPassphrase prompts for the vault passphrase on stderr without echoing it to the terminal
+func readVaultPassphrase() ([]byte, error) {
+	return mfa.PasswordPrompt("Vault passphrase: ")
+}
 
-	device.Run()
+//cachingPassphrase wraps a passphrase-reading function so it only prompts once, returning the same result
+//on every later call. vault.Open & v.Save each take their own passphrase function - without this, a vault
+//subcommand operating on an existing vault would prompt twice, and typing a different passphrase the
+//second time would silently re-encrypt the whole store under a new key.
+func cachingPassphrase(read func() ([]byte, error)) func() ([]byte, error) {
+	var pass []byte
+	var err error
+	var prompted bool
+	return func() ([]byte, error) {
+		if !prompted {
+			pass, err = read()
+			prompted = true
+		}
+		return pass, err
+	}
 }
@@ -0,0 +1,14 @@
+package mfa
+
+import "errors"
+
+//Sentinel errors returned instead of panicking by MFADevice & its functional options. ErrInsecurePerms is
+//the exception: SecretFromFile only reports it in a warning Event sent to the device's Writer, never as an
+//error. Wrap them with fmt.Errorf ("%w") for additional context - callers can still errors.Is against these.
+var (
+	ErrSecretUnreadable   = errors.New("mfa: secret could not be read")
+	ErrInsecurePerms      = errors.New("mfa: secret file has insecure permissions")
+	ErrInvalidAlgorithm   = errors.New("mfa: invalid OTP algorithm")
+	ErrTOTPGeneration     = errors.New("mfa: failed to generate TOTP code")
+	ErrCounterUnavailable = errors.New("mfa: no durable location to persist HOTP counter")
+)
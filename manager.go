@@ -0,0 +1,171 @@
+package mfa
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"gopkg.in/yaml.v3"
+)
+
+//Manager drives several MFADevices concurrently from a single process, ticking them together so their
+//codes redraw as one block of output instead of needing a separate `mfa` process per account.
+type Manager struct {
+	Devices         []*MFADevice
+	Writer          Writer
+	UpdateFrequency time.Duration
+}
+
+//NewManager creates a new Manager using functional options
+func NewManager(options ...func(*Manager)) *Manager {
+	m := &Manager{
+		Writer:          NewTerminal(),
+		UpdateFrequency: time.Second,
+	}
+	for _, o := range options {
+		o(m)
+	}
+	return m
+}
+
+//Devices is a functional option to add the MFADevices a Manager should drive
+func Devices(devices ...*MFADevice) func(*Manager) {
+	return func(m *Manager) {
+		m.Devices = append(m.Devices, devices...)
+	}
+}
+
+//ManagerOutput is a functional option to tell a Manager which Writer to send Events to
+func ManagerOutput(w Writer) func(*Manager) {
+	return func(m *Manager) {
+		if w != nil {
+			m.Writer = w
+		}
+	}
+}
+
+//ManagerUpdateFrequency is a functional option to tell a Manager how often to recalculate its Devices' codes
+func ManagerUpdateFrequency(p time.Duration) func(*Manager) {
+	return func(m *Manager) {
+		if p != 0 {
+			m.UpdateFrequency = p
+		}
+	}
+}
+
+//BlockWriter is implemented by Writers (Terminal) which can redraw a whole set of Events as a single unit.
+//Manager prefers this over Write/Warn/Error per Event so that driving several accounts through the same
+//Terminal renders one line per account instead of each Event's \r overwriting the last.
+type BlockWriter interface {
+	WriteBlock(events []Event) error
+}
+
+//Run ticks every UpdateFrequency, generating & writing an Event for every TOTP Device on the shared tick,
+//until ctx is cancelled. HOTP Devices are skipped - they're driven individually via Next(), not on a timer.
+func (m *Manager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.UpdateFrequency)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case t := <-ticker.C:
+			evts := make([]Event, 0, len(m.Devices))
+			for _, d := range m.Devices {
+				if d.Mode == ModeHOTP {
+					continue
+				}
+
+				evt := Event{
+					Time:             t,
+					Prefix:           d.Prefix,
+					Issuer:           d.Issuer,
+					RemainingSeconds: remainingSeconds(t, d.TOTPOptions.Period),
+				}
+
+				out, err := totp.GenerateCodeCustom(string(d.Secret), t, d.TOTPOptions)
+				switch {
+				case err != nil:
+					evt.Level = LevelError
+					evt.Message = fmt.Errorf("%w: %v", ErrTOTPGeneration, err).Error()
+				case t.Second() >= 55 || (t.Second() < 30 && t.Second() >= 25):
+					evt.Level = LevelWarn
+					evt.Message = out
+				default:
+					evt.Level = LevelInfo
+					evt.Message = out
+				}
+				evts = append(evts, evt)
+			}
+
+			if bw, ok := m.Writer.(BlockWriter); ok {
+				if err := bw.WriteBlock(evts); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, evt := range evts {
+				var err error
+				switch evt.Level {
+				case LevelError:
+					err = m.Writer.Error(evt)
+				case LevelWarn:
+					err = m.Writer.Warn(evt)
+				default:
+					err = m.Writer.Write(evt)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+//AccountConfig describes one account entry in a Manager config file
+type AccountConfig struct {
+	Prefix    string `yaml:"prefix"`
+	Issuer    string `yaml:"issuer"`
+	Secret    string `yaml:"secret"`
+	Algorithm string `yaml:"algorithm"`
+	Digits    int    `yaml:"digits"`
+	Period    uint   `yaml:"period"`
+}
+
+//ManagerConfig is the top-level shape of a YAML file listing the accounts a Manager should drive
+type ManagerConfig struct {
+	Accounts []AccountConfig `yaml:"accounts"`
+}
+
+//LoadManagerConfig reads a YAML file listing accounts & builds a Manager to drive all of them
+func LoadManagerConfig(path string) (*Manager, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading manager config %q: %w", path, err)
+	}
+
+	var cfg ManagerConfig
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing manager config %q: %w", path, err)
+	}
+
+	devices := make([]*MFADevice, 0, len(cfg.Accounts))
+	for _, a := range cfg.Accounts {
+		device, err := NewMFADevice(
+			Secret(a.Secret),
+			DevicePrefix(a.Prefix),
+			DeviceIssuer(a.Issuer),
+			Algorithm(a.Algorithm),
+			Digits(a.Digits),
+			RefreshPeriod(a.Period),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("building device %q from %q: %w", a.Prefix, path, err)
+		}
+		devices = append(devices, device)
+	}
+
+	return NewManager(Devices(devices...)), nil
+}
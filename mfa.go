@@ -1,13 +1,19 @@
 package mfa
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
 	"github.com/pquerna/otp/totp"
+	"github.com/rbrumby/mfa/vault"
 )
 
 type Color string
@@ -32,174 +38,363 @@ var algMap map[string]otp.Algorithm = map[string]otp.Algorithm{
 	"MD5":    otp.AlgorithmMD5,
 }
 
-var TerminalColors map[string]Color = map[string]Color{
-	"red":    Red,
-	"green":  Green,
-	"yellow": Yellow,
-	"blue":   Blue,
-	"purple": Purple,
-	"cyan":   Cyan,
-	"gray":   Gray,
-	"white":  White,
+//Level is the severity of an Event sent to a Writer
+type Level string
+
+const (
+	LevelInfo  Level = "info"
+	LevelWarn  Level = "warn"
+	LevelError Level = "error"
+)
+
+//Event is a structured OTP refresh (or error) message sent to a Writer. Using a struct instead of raw
+//bytes lets sinks other than a human-readable terminal (a structured logger, JSON file, syslog, a socket
+//server) consume the same data without scraping formatted text.
+type Event struct {
+	Level            Level
+	Time             time.Time
+	Prefix           string
+	Issuer           string
+	Message          string
+	RemainingSeconds int
 }
 
-//Writer is an interface which an MFADevce will write to
+//Writer is an interface which an MFADevce will write Events to
 type Writer interface {
-	Write(p []byte) error
-	Warn(p []byte) error
-	Error(p []byte) error
+	Write(e Event) error
+	Warn(e Event) error
+	Error(e Event) error
 }
 
-//Terminsl is a Writer for writing out OTP codes & error/warning messages
-type Terminal struct {
-	Pattern      string
-	Prefix       string
-	DefaultColor Color
-	WarningColor Color
-	ErrorColor   Color
+//OTPMode determines whether an MFADevice generates TOTP (time-based) or HOTP (counter-based) codes
+type OTPMode string
+
+const (
+	ModeTOTP OTPMode = "totp"
+	ModeHOTP OTPMode = "hotp"
+)
+
+type MFADevice struct {
+	Secret          []byte
+	Writer          Writer
+	UpdateFrequency time.Duration
+	TOTPOptions     totp.ValidateOpts
+	Mode            OTPMode
+	Counter         uint64
+	HOTPOptions     hotp.ValidateOpts
+	//Prefix & Issuer identify this Device's Events when several are driven together by a Manager
+	Prefix string
+	Issuer string
+
+	secretFile    string
+	counterKey    string
+	counterLoaded bool
+	err           error
 }
 
-//NewTerminal creates a new Terminal using functional options to set the output colors
-func NewTerminal(options ...func(*Terminal)) *Terminal {
-	term := &Terminal{
-		Pattern:      "\r%s%s [%s] %s",
-		Prefix:       "default",
-		DefaultColor: Green,
-		WarningColor: Cyan,
-		ErrorColor:   Red,
+//NewMFADevice creates a new MFADevice using functional options. It returns an error if any option
+//couldn't be applied (e.g. an unreadable secret file or an unrecognised algorithm) rather than panicking,
+//so the library can be embedded in long-running processes & test suites.
+func NewMFADevice(options ...func(*MFADevice)) (*MFADevice, error) {
+	//By default use a Terminal
+	term := NewTerminal()
+
+	device := &MFADevice{
+		TOTPOptions:     totp.ValidateOpts{},
+		HOTPOptions:     hotp.ValidateOpts{},
+		Mode:            ModeTOTP,
+		Writer:          term,
+		UpdateFrequency: time.Second,
 	}
 	for _, o := range options {
-		o(term)
+		o(device)
+	}
+	if device.err != nil {
+		return nil, device.err
 	}
-	return term
+	return device, nil
 }
 
-//Prefix is a functional option for setting a prefix to help identify an OTP (if you have multiple running)
-func Prefix(pre string) func(*Terminal) {
-	return func(t *Terminal) {
-		if pre != "" {
-			t.Prefix = pre
+//Err returns the first error recorded by a functional option passed to NewMFADevice, or one applied
+//directly to the device afterwards - useful when options are applied outside of NewMFADevice's call, e.g.
+//to pick a secret source at runtime.
+func (d *MFADevice) Err() error {
+	return d.err
+}
+
+//Run runs the MFADevice, ticking every UpdateFrequency & writing the current code to its Writer, until ctx
+//is cancelled - at which point it returns nil after printing a final newline so the next line of output
+//doesn't start mid-way through an overwritten code. Run only supports TOTP mode - for HOTP, use Next()
+//instead since a counter-based code shouldn't be regenerated until the caller actually asks for the next one.
+func (d *MFADevice) Run(ctx context.Context) error {
+	if d.Mode == ModeHOTP {
+		d.Writer.Error(Event{
+			Level:   LevelError,
+			Time:    time.Now(),
+			Prefix:  d.Prefix,
+			Issuer:  d.Issuer,
+			Message: "Run() doesn't support HOTP mode - call Next() instead",
+		})
+		return fmt.Errorf("Run() doesn't support HOTP mode - call Next() instead")
+	}
+	ticker := time.NewTicker(d.UpdateFrequency)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println()
+			return nil
+		case t := <-ticker.C:
+			out, err := totp.GenerateCodeCustom(string(d.Secret), t, d.TOTPOptions)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrTOTPGeneration, err)
+			}
+			evt := Event{
+				Time:             t,
+				Prefix:           d.Prefix,
+				Issuer:           d.Issuer,
+				Message:          out,
+				RemainingSeconds: remainingSeconds(t, d.TOTPOptions.Period),
+			}
+			if t.Second() >= 55 || (t.Second() < 30 && t.Second() >= 25) {
+				evt.Level = LevelWarn
+				if err := d.Writer.Warn(evt); err != nil {
+					return err
+				}
+			} else {
+				evt.Level = LevelInfo
+				if err := d.Writer.Write(evt); err != nil {
+					return err
+				}
+			}
 		}
 	}
 }
 
-//DefaultColor is a functional option for setting the terminal default text color
-func DefaultColor(c Color) func(*Terminal) {
-	return func(t *Terminal) {
-		if c != "" {
-			t.DefaultColor = c
-		}
+//remainingSeconds returns how many seconds are left before a TOTP generated at t with the given period expires
+func remainingSeconds(t time.Time, period uint) int {
+	if period == 0 {
+		period = 30
 	}
+	return int(int64(period) - t.Unix()%int64(period))
 }
 
-//WarningColor is a functional option for setting the terminal warning text color
-func WarningColor(c Color) func(*Terminal) {
-	return func(t *Terminal) {
-		if c != "" {
-			t.WarningColor = c
-		}
+//Next generates the next HOTP code, advancing the counter & persisting it back to a sidecar file so that
+//restarting the process doesn't replay a code. Next refuses to run if it can't find a durable place to
+//persist that counter - with no secret file, vault entry or otpauth issuer/account to key a sidecar off,
+//silently starting back at the loaded (or zero) counter every run would itself be a replay.
+func (d *MFADevice) Next() (string, error) {
+	if d.Mode != ModeHOTP {
+		return "", fmt.Errorf("Next is only valid in HOTP mode")
 	}
+	if d.counterFile() == "" {
+		return "", ErrCounterUnavailable
+	}
+	if !d.counterLoaded {
+		d.loadCounter()
+		d.counterLoaded = true
+	}
+	out, err := hotp.GenerateCodeCustom(string(d.Secret), d.Counter, d.HOTPOptions)
+	if err != nil {
+		return "", err
+	}
+	d.Counter++
+	if err := d.persistCounter(); err != nil {
+		return "", err
+	}
+	return out, nil
 }
 
-//ErrorColor is a functional option for setting the terminal error text color
-func ErrorColor(c Color) func(*Terminal) {
-	return func(t *Terminal) {
-		if c != "" {
-			t.ErrorColor = c
+//counterFile is the sidecar file Next() persists the HOTP counter to: alongside the secret
+//(<secret-file>.counter) when one was loaded from a file, or under counterDir() keyed by counterKey for a
+//vault entry or otpauth:// URL. Returns "" if neither is set, e.g. for a raw -secret with no stable identity.
+func (d *MFADevice) counterFile() string {
+	switch {
+	case d.secretFile != "":
+		return d.secretFile + ".counter"
+	case d.counterKey != "":
+		dir, err := counterDir()
+		if err != nil {
+			return ""
 		}
+		return filepath.Join(dir, d.counterKey+".counter")
+	default:
+		return ""
 	}
 }
 
-//Write writes in the DefaultColor of the Terminal
-func (t *Terminal) Write(p []byte) error {
-	fmt.Fprintf(os.Stdout, t.Pattern, t.DefaultColor, t.Prefix, time.Now().Format(time.RFC3339), p)
-	return nil
+//counterDir is where HOTP counters are persisted for secrets with no backing file of their own
+func counterDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".mfa", "counters"), nil
 }
 
-//Warn writes in the WarningColor of the Terminal
-func (t *Terminal) Warn(p []byte) error {
-	fmt.Fprintf(os.Stdout, t.Pattern, t.WarningColor, t.Prefix, time.Now().Format(time.RFC3339), p)
-	return nil
+//counterKeyFor builds a filesystem-safe key identifying a non-file HOTP secret source (a vault entry or an
+//otpauth:// issuer/account) so counterFile has something stable to persist its sidecar under
+func counterKeyFor(parts ...string) string {
+	unsafe := strings.NewReplacer("/", "_", "\\", "_", ":", "_", " ", "_")
+	return unsafe.Replace(strings.Join(parts, "-"))
 }
 
-//Error writes in the ErrorColor of the Terminal
-func (t *Terminal) Error(p []byte) error {
-	fmt.Fprintf(os.Stderr, t.Pattern, t.ErrorColor, t.Prefix, time.Now().Format(time.RFC3339), p)
-	return nil
+//loadCounter reads a previously persisted HOTP counter from its sidecar file, if one exists
+func (d *MFADevice) loadCounter() {
+	file := d.counterFile()
+	if file == "" {
+		return
+	}
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	counter, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return
+	}
+	d.Counter = counter
 }
 
-type MFADevice struct {
-	Secret          []byte
-	Writer          Writer
-	UpdateFrequency time.Duration
-	TOTPOptions     totp.ValidateOpts
+//persistCounter writes the current HOTP counter to its sidecar file so a restart doesn't replay a code
+func (d *MFADevice) persistCounter() error {
+	file := d.counterFile()
+	if file == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return fmt.Errorf("persisting HOTP counter: %w", err)
+	}
+	return ioutil.WriteFile(file, []byte(strconv.FormatUint(d.Counter, 10)), 0o600)
 }
 
-//NewMFADevice creates a new MFADevice using functional options
-func NewMFADevice(options ...func(*MFADevice)) *MFADevice {
-	//By default use a Terminal
-	term := NewTerminal()
-
-	device := &MFADevice{
-		TOTPOptions:     totp.ValidateOpts{},
-		Writer:          term,
-		UpdateFrequency: time.Second,
+//Secret is a functional option to set a secret on an MFADevice
+func Secret(secret string) func(*MFADevice) {
+	return func(d *MFADevice) {
+		if secret != "" {
+			d.Secret = []byte(secret)
+		}
 	}
-	for _, o := range options {
-		o(device)
+}
+
+//SecretFromFile is a functional option to tell an MFADevice to read the secret from a file. An insecure
+//file permission only produces a warning via the device's Writer; an unreadable file sets Err() to
+//ErrSecretUnreadable.
+func SecretFromFile(file *os.File) func(*MFADevice) {
+	return func(d *MFADevice) {
+		if file == nil {
+			return
+		}
+		stat, err := file.Stat()
+		if err != nil {
+			d.setErr(fmt.Errorf("%w: %v", ErrSecretUnreadable, err))
+			return
+		}
+		if stat.Mode().Perm()&0o077 != 0 {
+			d.Writer.Warn(Event{
+				Level:   LevelWarn,
+				Time:    time.Now(),
+				Prefix:  d.Prefix,
+				Issuer:  d.Issuer,
+				Message: fmt.Errorf("%w: %q", ErrInsecurePerms, file.Name()).Error(),
+			})
+		}
+
+		secret, err := ioutil.ReadAll(file)
+		if err != nil {
+			d.setErr(fmt.Errorf("%w: %v", ErrSecretUnreadable, err))
+			return
+		}
+		d.Secret = secret
+		d.secretFile = file.Name()
 	}
-	return device
 }
 
-//Run runs the MFADevice
-func (d *MFADevice) Run() {
-	ticker := time.NewTicker(d.UpdateFrequency)
-	for {
-		t := <-ticker.C
-		out, err := totp.GenerateCodeCustom(string(d.Secret), t, d.TOTPOptions)
+//SecretFromVault is a functional option to tell an MFADevice to read its secret from a named entry in an
+//encrypted vault.Vault at path, obtaining the vault passphrase from the supplied function. It replaces
+//SecretFromFile for users who'd rather not keep their OTP secret in a plaintext file.
+func SecretFromVault(path string, name string, passphrase func() ([]byte, error)) func(*MFADevice) {
+	return func(d *MFADevice) {
+		v, err := vault.Open(path, passphrase)
 		if err != nil {
-			panic(err)
+			d.setErr(fmt.Errorf("%w: %v", ErrSecretUnreadable, err))
+			return
 		}
-		if t.Second() >= 55 || (t.Second() < 30 && t.Second() >= 25) {
-			err = d.Writer.Warn([]byte(out))
-			if err != nil {
-				panic(err)
-			}
-		} else {
-			err = d.Writer.Write([]byte(out))
-			if err != nil {
-				panic(err)
-			}
+		secret, ok := v.Get(name)
+		if !ok {
+			d.setErr(fmt.Errorf("%w: no entry named %q in vault %q", ErrSecretUnreadable, name, path))
+			return
+		}
+		d.Secret = secret
+		if d.Mode == ModeHOTP {
+			d.counterKey = counterKeyFor("vault", filepath.Base(path), name)
 		}
 	}
 }
 
-//Secret is a functional option to set a secret on an MFADevice
-func Secret(secret string) func(*MFADevice) {
+//setErr records the first error encountered by a functional option - later options still run (so e.g. a
+//Writer option still takes effect) but NewMFADevice will return this error instead of the device
+func (d *MFADevice) setErr(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+//Mode is a functional option to select TOTP (time-based, the default) or HOTP (counter-based) code generation
+func Mode(mode string) func(*MFADevice) {
 	return func(d *MFADevice) {
-		if secret != "" {
-			d.Secret = []byte(secret)
+		switch OTPMode(mode) {
+		case ModeHOTP:
+			d.Mode = ModeHOTP
+		case ModeTOTP, "":
+			d.Mode = ModeTOTP
 		}
 	}
 }
 
-//SecretFromFile is a functional option to tell an MFADevice to read the secret from a file
-func SecretFromFile(file *os.File) func(*MFADevice) {
+//FromOTPAuthURL is a functional option which configures an MFADevice's secret, algorithm, digits & mode
+//(plus period for TOTP or counter for HOTP) in one call by parsing an otpauth:// provisioning URI, as
+//produced by most authenticator apps & their QR codes.
+func FromOTPAuthURL(uri string) func(*MFADevice) {
 	return func(d *MFADevice) {
-		if file != nil {
-			stat, err := file.Stat()
-			if err != nil {
-				panic(err)
-			}
-			if stat.Mode().Perm() > 0o700 {
-				d.Writer.Warn([]byte(fmt.Sprintf("WARNING - secret file %q is not secure\n", file.Name())))
+		key, err := otp.NewKeyFromURL(uri)
+		if err != nil {
+			d.setErr(fmt.Errorf("%w: %v", ErrSecretUnreadable, err))
+			return
+		}
+		d.Secret = []byte(key.Secret())
+		switch key.Type() {
+		case string(ModeHOTP):
+			d.Mode = ModeHOTP
+			d.HOTPOptions.Digits = key.Digits()
+			d.HOTPOptions.Algorithm = key.Algorithm()
+			if counter, err := strconv.ParseUint(key.Query().Get("counter"), 10, 64); err == nil {
+				d.Counter = counter
 			}
+			d.counterKey = counterKeyFor("otpauth", key.Issuer(), key.AccountName())
+		default:
+			d.Mode = ModeTOTP
+			d.TOTPOptions.Digits = key.Digits()
+			d.TOTPOptions.Algorithm = key.Algorithm()
+			d.TOTPOptions.Period = uint(key.Period())
+		}
+	}
+}
 
-			secret, err := ioutil.ReadAll(file)
-			if err != nil {
-				panic(err)
-			}
-			d.Secret = secret
+//DevicePrefix is a functional option to identify an MFADevice's Events when several are driven together by a Manager
+func DevicePrefix(pre string) func(*MFADevice) {
+	return func(d *MFADevice) {
+		if pre != "" {
+			d.Prefix = pre
+		}
+	}
+}
+
+//DeviceIssuer is a functional option to attach an issuer name to an MFADevice's Events
+func DeviceIssuer(issuer string) func(*MFADevice) {
+	return func(d *MFADevice) {
+		if issuer != "" {
+			d.Issuer = issuer
 		}
 	}
 }
@@ -230,16 +425,29 @@ func RefreshPeriod(per uint) func(*MFADevice) {
 	}
 }
 
+//Digits is a functional option setting the number of digits in a generated code. It's applied to both
+//TOTPOptions & HOTPOptions since Mode may be set before or after this option runs.
 func Digits(dig int) func(*MFADevice) {
 	return func(d *MFADevice) {
-		d.TOTPOptions.Digits = otp.Digits(dig)
+		digits := otp.Digits(dig)
+		d.TOTPOptions.Digits = digits
+		d.HOTPOptions.Digits = digits
 	}
 }
 
+//Algorithm is a functional option setting the HMAC algorithm used to generate a code. It's applied to both
+//TOTPOptions & HOTPOptions since Mode may be set before or after this option runs.
 func Algorithm(alg string) func(*MFADevice) {
 	return func(d *MFADevice) {
-		if alg != "" {
-			d.TOTPOptions.Algorithm = algMap[alg]
+		if alg == "" {
+			return
+		}
+		a, ok := algMap[alg]
+		if !ok {
+			d.setErr(fmt.Errorf("%w: %q", ErrInvalidAlgorithm, alg))
+			return
 		}
+		d.TOTPOptions.Algorithm = a
+		d.HOTPOptions.Algorithm = a
 	}
 }
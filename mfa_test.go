@@ -0,0 +1,73 @@
+package mfa
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func deviceFromSecretFile(t *testing.T, path string) *MFADevice {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening secret file: %v", err)
+	}
+	device, err := NewMFADevice(Mode(string(ModeHOTP)), Digits(6))
+	if err != nil {
+		t.Fatalf("NewMFADevice: %v", err)
+	}
+	SecretFromFile(f)(device)
+	if device.Err() != nil {
+		t.Fatalf("SecretFromFile: %v", device.Err())
+	}
+	return device
+}
+
+//TestNextPersistsCounterAcrossRestarts exercises the exact replay a reviewer flagged: a CLI invocation only
+//lives long enough to print one HOTP code & exit, so the counter has to survive on disk or every run would
+//hand out the same code.
+func TestNextPersistsCounterAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(secretFile, []byte("JBSWY3DPEHPK3PXP"), 0o600); err != nil {
+		t.Fatalf("writing secret file: %v", err)
+	}
+
+	first := deviceFromSecretFile(t, secretFile)
+	code1, err := first.Next()
+	if err != nil {
+		t.Fatalf("Next (1st device, 1st code): %v", err)
+	}
+	code2, err := first.Next()
+	if err != nil {
+		t.Fatalf("Next (1st device, 2nd code): %v", err)
+	}
+	if code1 == code2 {
+		t.Fatalf("successive Next() calls returned the same code %q - counter isn't advancing", code1)
+	}
+
+	//A fresh MFADevice reading the same secret file simulates a restarted process - it should pick up
+	//where the first one left off, not replay code1 or code2
+	second := deviceFromSecretFile(t, secretFile)
+	code3, err := second.Next()
+	if err != nil {
+		t.Fatalf("Next (2nd device, 1st code): %v", err)
+	}
+	if code3 == code1 || code3 == code2 {
+		t.Fatalf("restarted device replayed a previous code %q", code3)
+	}
+}
+
+//TestNextRefusesWithNoDurableCounterLocation covers a raw -secret with no file, vault entry or otpauth
+//issuer/account to key a sidecar off - Next must refuse rather than silently replaying from counter 0.
+func TestNextRefusesWithNoDurableCounterLocation(t *testing.T) {
+	device, err := NewMFADevice(Mode(string(ModeHOTP)), Secret("JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatalf("NewMFADevice: %v", err)
+	}
+	if _, err := device.Next(); !errors.Is(err, ErrCounterUnavailable) {
+		t.Fatalf("Next() with a raw secret & no counter location = %v; want ErrCounterUnavailable", err)
+	}
+}
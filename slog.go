@@ -0,0 +1,44 @@
+package mfa
+
+import (
+	"context"
+	"log/slog"
+)
+
+//SlogWriter is a Writer that emits structured, leveled log records via log/slog rather than writing
+//human-oriented text, so Events can be routed to JSON, syslog or any other slog handler.
+type SlogWriter struct {
+	Logger *slog.Logger
+}
+
+//NewSlogWriter creates a SlogWriter around logger, falling back to slog.Default() if logger is nil
+func NewSlogWriter(logger *slog.Logger) *SlogWriter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogWriter{Logger: logger}
+}
+
+//Write logs an Event at Info level
+func (w *SlogWriter) Write(e Event) error {
+	return w.log(slog.LevelInfo, e)
+}
+
+//Warn logs an Event at Warn level
+func (w *SlogWriter) Warn(e Event) error {
+	return w.log(slog.LevelWarn, e)
+}
+
+//Error logs an Event at Error level
+func (w *SlogWriter) Error(e Event) error {
+	return w.log(slog.LevelError, e)
+}
+
+func (w *SlogWriter) log(level slog.Level, e Event) error {
+	w.Logger.Log(context.Background(), level, e.Message,
+		"prefix", e.Prefix,
+		"issuer", e.Issuer,
+		"remaining_seconds", e.RemainingSeconds,
+	)
+	return nil
+}
@@ -0,0 +1,134 @@
+package mfa
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+//SocketServer is a Writer that serves the current OTP as JSON to local clients over a Unix domain socket
+//(or localhost TCP), so editor plugins, browser extensions or shell functions can fetch codes without
+//screen-scraping a terminal.
+type SocketServer struct {
+	mu       sync.Mutex
+	latest   Event
+	listener net.Listener
+}
+
+//socketResponse is the JSON payload served to clients
+type socketResponse struct {
+	OTP       string `json:"otp"`
+	ExpiresIn int    `json:"expires_in"`
+	Issuer    string `json:"issuer,omitempty"`
+}
+
+//NewSocketServer starts listening on addr & serving the latest code to anyone who connects. addr is
+//either a filesystem path (a Unix domain socket, secured to 0600 & the calling user) or a host:port
+//(localhost TCP only - there's no authentication on this path, so binding any other interface would serve
+//live OTP codes to the whole network).
+func NewSocketServer(addr string) (*SocketServer, error) {
+	s := &SocketServer{}
+
+	if strings.Contains(addr, ":") {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing socket address %q: %w", addr, err)
+		}
+		host, ok := loopbackHost(host)
+		if !ok {
+			return nil, fmt.Errorf(
+				"socket address %q must bind to loopback (127.0.0.1, ::1 or localhost) - "+
+					"any other host would serve OTP codes to the whole network with no authentication", addr)
+		}
+		l, err := net.Listen("tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			return nil, fmt.Errorf("listening on %q: %w", addr, err)
+		}
+		s.listener = l
+	} else {
+		if err := os.Remove(addr); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("removing stale socket %q: %w", addr, err)
+		}
+		l, err := net.Listen("unix", addr)
+		if err != nil {
+			return nil, fmt.Errorf("listening on %q: %w", addr, err)
+		}
+		if err := os.Chmod(addr, 0o600); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("securing socket %q: %w", addr, err)
+		}
+		s.listener = l
+	}
+
+	go s.serve()
+	return s, nil
+}
+
+//loopbackHost normalizes host to a loopback address NewSocketServer can bind to, or reports it can't: ""
+//(e.g. from "-output socket::8080", which would otherwise bind every interface) & "localhost" both become
+//"127.0.0.1"; any other address is only accepted if it's already a loopback IP.
+func loopbackHost(host string) (string, bool) {
+	if host == "" || host == "localhost" {
+		return "127.0.0.1", true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return host, true
+	}
+	return "", false
+}
+
+func (s *SocketServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+//handle rejects peers that don't pass allowedPeer (SO_PEERCRED on Linux Unix sockets) before serving them
+//the latest code - the 0600 file permission alone doesn't stop another user on a misconfigured system
+func (s *SocketServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	if !allowedPeer(conn) {
+		return
+	}
+
+	s.mu.Lock()
+	evt := s.latest
+	s.mu.Unlock()
+
+	json.NewEncoder(conn).Encode(socketResponse{
+		OTP:       evt.Message,
+		ExpiresIn: evt.RemainingSeconds,
+		Issuer:    evt.Issuer,
+	})
+}
+
+//Write records the latest code so it's ready to serve to the next client that connects
+func (s *SocketServer) Write(e Event) error {
+	s.mu.Lock()
+	s.latest = e
+	s.mu.Unlock()
+	return nil
+}
+
+//Warn also just records the latest code - clients can see expires_in is low for themselves
+func (s *SocketServer) Warn(e Event) error {
+	return s.Write(e)
+}
+
+//Error is a no-op - there's no code to serve when generation failed
+func (s *SocketServer) Error(e Event) error {
+	return nil
+}
+
+//Close stops accepting new connections
+func (s *SocketServer) Close() error {
+	return s.listener.Close()
+}
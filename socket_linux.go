@@ -0,0 +1,32 @@
+//go:build linux
+
+package mfa
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+//allowedPeer rejects Unix domain socket peers that don't share our UID, using SO_PEERCRED. This is the
+//real access control for SocketServer - the socket file's 0600 permission alone can be loosened by a
+//misconfigured umask or a shared home directory.
+func allowedPeer(conn net.Conn) bool {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		//Not a Unix socket (e.g. localhost TCP) - nothing to check peer credentials against
+		return true
+	}
+
+	file, err := unixConn.File()
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	ucred, err := syscall.GetsockoptUcred(int(file.Fd()), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	if err != nil {
+		return false
+	}
+	return ucred.Uid == uint32(os.Getuid())
+}
@@ -0,0 +1,199 @@
+package mfa
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	xterm "golang.org/x/term"
+)
+
+var TerminalColors map[string]Color = map[string]Color{
+	"red":    Red,
+	"green":  Green,
+	"yellow": Yellow,
+	"blue":   Blue,
+	"purple": Purple,
+	"cyan":   Cyan,
+	"gray":   Gray,
+	"white":  White,
+}
+
+//Terminsl is a Writer for writing out OTP codes & error/warning messages. It detects whether stdout is a
+//real TTY (rather than e.g. a pipe or a legacy Windows console that can't render ANSI) & degrades to
+//plain, uncoloured text rather than emitting escape sequences that would show up as literal junk.
+type Terminal struct {
+	Pattern      string
+	Prefix       string
+	DefaultColor Color
+	WarningColor Color
+	ErrorColor   Color
+
+	//interactive is whether stdout is a real, ANSI-capable TTY. It gates both color & the clearLine
+	//sequence in Pattern - writing either to a pipe or file would leave escape junk behind.
+	interactive bool
+	//blockLines is how many lines WriteBlock last rendered, so the next call knows how far to move the
+	//cursor back up before redrawing
+	blockLines int
+}
+
+//NewTerminal creates a new Terminal using functional options to set the output colors. Colors (and the
+//ANSI clear-line sequence) are disabled automatically when stdout isn't a TTY, when NO_COLOR is set, or (on
+//legacy Windows consoles) when ANSI virtual terminal processing can't be enabled.
+func NewTerminal(options ...func(*Terminal)) *Terminal {
+	term := &Terminal{
+		Prefix:       "default",
+		DefaultColor: Green,
+		WarningColor: Cyan,
+		ErrorColor:   Red,
+		interactive:  supportsColor(),
+	}
+	if term.interactive {
+		term.Pattern = clearLine + "%s%s [%s] %s"
+	} else {
+		term.DefaultColor = ""
+		term.WarningColor = ""
+		term.ErrorColor = ""
+		term.Pattern = "%s%s [%s] %s\n"
+	}
+	for _, o := range options {
+		o(term)
+	}
+	return term
+}
+
+//supportsColor reports whether it's safe to write ANSI color codes to stdout
+func supportsColor() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if !xterm.IsTerminal(int(os.Stdout.Fd())) {
+		return false
+	}
+	return enableVirtualTerminal() == nil
+}
+
+//Prefix is a functional option for setting a prefix to help identify an OTP (if you have multiple running)
+func Prefix(pre string) func(*Terminal) {
+	return func(t *Terminal) {
+		if pre != "" {
+			t.Prefix = pre
+		}
+	}
+}
+
+//DefaultColor is a functional option for setting the terminal default text color
+func DefaultColor(c Color) func(*Terminal) {
+	return func(t *Terminal) {
+		if c != "" {
+			t.DefaultColor = c
+		}
+	}
+}
+
+//WarningColor is a functional option for setting the terminal warning text color
+func WarningColor(c Color) func(*Terminal) {
+	return func(t *Terminal) {
+		if c != "" {
+			t.WarningColor = c
+		}
+	}
+}
+
+//ErrorColor is a functional option for setting the terminal error text color
+func ErrorColor(c Color) func(*Terminal) {
+	return func(t *Terminal) {
+		if c != "" {
+			t.ErrorColor = c
+		}
+	}
+}
+
+//Write writes an Event in the DefaultColor of the Terminal
+func (t *Terminal) Write(e Event) error {
+	fmt.Fprintf(os.Stdout, t.Pattern, t.DefaultColor, t.prefixFor(e), t.timeFor(e), e.Message)
+	return nil
+}
+
+//Warn writes an Event in the WarningColor of the Terminal
+func (t *Terminal) Warn(e Event) error {
+	fmt.Fprintf(os.Stdout, t.Pattern, t.WarningColor, t.prefixFor(e), t.timeFor(e), e.Message)
+	return nil
+}
+
+//Error writes an Event in the ErrorColor of the Terminal
+func (t *Terminal) Error(e Event) error {
+	fmt.Fprintf(os.Stderr, t.Pattern, t.ErrorColor, t.prefixFor(e), t.timeFor(e), e.Message)
+	return nil
+}
+
+//WriteBlock renders a set of Events as one line each, redrawn as a unit on every call instead of each
+//Event independently emitting its own \r & overwriting the others - that's what lets a Manager driving
+//several accounts through a Terminal show one line per account rather than clobbering all but the last.
+//On a non-interactive sink (no TTY to redraw in place on) it just appends a fresh newline-separated block.
+func (t *Terminal) WriteBlock(events []Event) error {
+	var out strings.Builder
+	if t.interactive && t.blockLines > 0 {
+		fmt.Fprintf(&out, "\033[%dA", t.blockLines)
+	}
+	for _, e := range events {
+		if t.interactive {
+			out.WriteString(clearLine)
+		}
+		fmt.Fprintf(&out, "%s%s [%s] %s\n", t.colorFor(e), t.prefixFor(e), t.timeFor(e), e.Message)
+	}
+	if t.interactive {
+		t.blockLines = len(events)
+	}
+	_, err := fmt.Fprint(os.Stdout, out.String())
+	return err
+}
+
+//colorFor picks the Terminal's DefaultColor/WarningColor/ErrorColor for an Event based on its Level
+func (t *Terminal) colorFor(e Event) Color {
+	switch e.Level {
+	case LevelWarn:
+		return t.WarningColor
+	case LevelError:
+		return t.ErrorColor
+	default:
+		return t.DefaultColor
+	}
+}
+
+//prefixFor falls back to the Terminal's own static Prefix when an Event doesn't carry one - e.g. when a
+//single MFADevice (rather than a Manager driving several with their own Prefix) is writing to it
+func (t *Terminal) prefixFor(e Event) string {
+	if e.Prefix != "" {
+		return e.Prefix
+	}
+	return t.Prefix
+}
+
+//timeFor falls back to the current time when an Event doesn't carry one
+func (t *Terminal) timeFor(e Event) string {
+	if e.Time.IsZero() {
+		return time.Now().Format(time.RFC3339)
+	}
+	return e.Time.Format(time.RFC3339)
+}
+
+//PasswordPrompt prints prompt to stderr & reads a passphrase from stdin, on Linux, macOS or Windows. When
+//stdin is a real terminal it disables echo while reading; xterm.ReadPassword needs a TTY to do that, so
+//when stdin is piped (e.g. from a secrets manager) it falls back to reading a single line instead.
+func PasswordPrompt(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if !xterm.IsTerminal(int(os.Stdin.Fd())) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		return []byte(strings.TrimRight(line, "\r\n")), nil
+	}
+	pass, err := xterm.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return pass, err
+}
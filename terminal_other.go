@@ -0,0 +1,12 @@
+//go:build !windows
+
+package mfa
+
+//clearLine is written before each refreshed line. On real terminals "\033[K" (clear to end of line) after
+//the carriage return is enough to erase any stale digits left over from a longer previous code.
+const clearLine = "\r\033[K"
+
+//enableVirtualTerminal is a no-op outside Windows - ANSI escape sequences already work natively
+func enableVirtualTerminal() error {
+	return nil
+}
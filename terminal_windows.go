@@ -0,0 +1,28 @@
+//go:build windows
+
+package mfa
+
+import (
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+//clearLine pads over a previous line with spaces before returning the cursor to the start of it. Legacy
+//Windows consoles don't reliably support "\033[K" (clear to end of line), so overwrite with blanks instead.
+var clearLine = "\r" + strings.Repeat(" ", 80) + "\r"
+
+//enableVirtualTerminal turns on ENABLE_VIRTUAL_TERMINAL_PROCESSING for stdout so ANSI escape sequences
+//(colors, clear-to-end-of-line) render correctly instead of as literal junk on older Windows consoles.
+func enableVirtualTerminal() error {
+	handle := windows.Handle(windows.Stdout)
+
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return err
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return nil
+	}
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+}
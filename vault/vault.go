@@ -0,0 +1,184 @@
+//Package vault stores one or more named OTP secrets in a single file, encrypted with a passphrase
+//instead of the plaintext secret files mfa otherwise reads.
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var magic = []byte("MFAV")
+
+const version byte = 1
+
+//Argon2id parameters used when a new Vault is saved. These aren't tunable per-Vault - if they need to
+//change, bump version & read the old parameters from the header when decrypting older files.
+const (
+	argonTime        uint32 = 1
+	argonMemory      uint32 = 64 * 1024
+	argonParallelism byte   = 4
+)
+
+const saltSize = 16
+
+//headerSize is the size, in bytes, of everything in the file before the ciphertext:
+//magic(4) | version(1) | argon2_time(4) | argon2_memory(4) | argon2_parallelism(1) | salt(16) | nonce(24)
+const headerSize = 4 + 1 + 4 + 4 + 1 + saltSize + chacha20poly1305.NonceSizeX
+
+//Vault is an encrypted, passphrase-protected store of named OTP secrets
+type Vault struct {
+	path    string
+	entries map[string][]byte
+}
+
+//New creates an empty Vault which will be written to path by Save
+func New(path string) *Vault {
+	return &Vault{
+		path:    path,
+		entries: map[string][]byte{},
+	}
+}
+
+//Open reads & decrypts the Vault at path, obtaining the passphrase to derive its key from the supplied function
+func Open(path string, passphrase func() ([]byte, error)) (*Vault, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading vault %q: %w", path, err)
+	}
+	if len(raw) < headerSize {
+		return nil, fmt.Errorf("vault %q is too short to be valid", path)
+	}
+
+	offset := 0
+	if !bytes.Equal(raw[offset:offset+len(magic)], magic) {
+		return nil, fmt.Errorf("vault %q has an invalid header", path)
+	}
+	offset += len(magic)
+
+	if raw[offset] != version {
+		return nil, fmt.Errorf("vault %q has unsupported version %d", path, raw[offset])
+	}
+	offset++
+
+	time := binary.BigEndian.Uint32(raw[offset : offset+4])
+	offset += 4
+	memory := binary.BigEndian.Uint32(raw[offset : offset+4])
+	offset += 4
+	parallelism := raw[offset]
+	offset++
+	salt := raw[offset : offset+saltSize]
+	offset += saltSize
+	nonce := raw[offset : offset+chacha20poly1305.NonceSizeX]
+	offset += chacha20poly1305.NonceSizeX
+	ciphertext := raw[offset:]
+
+	pass, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	key := argon2.IDKey(pass, salt, time, memory, parallelism, chacha20poly1305.KeySize)
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt vault - wrong passphrase or corrupt file")
+	}
+
+	entries := map[string][]byte{}
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return nil, fmt.Errorf("decoding vault %q contents: %w", path, err)
+	}
+
+	return &Vault{path: path, entries: entries}, nil
+}
+
+//Add stores a named secret in the Vault, overwriting any existing entry with the same name. Call Save
+//to persist the change.
+func (v *Vault) Add(name string, secret []byte) {
+	v.entries[name] = secret
+}
+
+//Remove deletes a named secret from the Vault. Call Save to persist the change.
+func (v *Vault) Remove(name string) {
+	delete(v.entries, name)
+}
+
+//Get returns the secret stored under name, & whether an entry by that name was found
+func (v *Vault) Get(name string) ([]byte, bool) {
+	secret, ok := v.entries[name]
+	return secret, ok
+}
+
+//List returns the names of all entries in the Vault, sorted alphabetically
+func (v *Vault) List() []string {
+	names := make([]string, 0, len(v.entries))
+	for name := range v.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Save encrypts the Vault's entries with a key derived from the passphrase returned by the supplied
+//function & writes the result to its path, using a freshly generated salt & nonce.
+func (v *Vault) Save(passphrase func() ([]byte, error)) error {
+	plaintext, err := json.Marshal(v.entries)
+	if err != nil {
+		return err
+	}
+
+	pass, err := passphrase()
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	key := argon2.IDKey(pass, salt, argonTime, argonMemory, argonParallelism, chacha20poly1305.KeySize)
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	ciphertext := aead.Seal(nil, nonce, plaintext, nil)
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = append(header, version)
+	header = appendUint32(header, argonTime)
+	header = appendUint32(header, argonMemory)
+	header = append(header, argonParallelism)
+	header = append(header, salt...)
+	header = append(header, nonce...)
+
+	if err := os.MkdirAll(filepath.Dir(v.path), 0o700); err != nil {
+		return fmt.Errorf("creating vault directory for %q: %w", v.path, err)
+	}
+	return ioutil.WriteFile(v.path, append(header, ciphertext...), 0o600)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, v)
+	return append(b, buf...)
+}
@@ -0,0 +1,72 @@
+package vault
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func passphraseOf(s string) func() ([]byte, error) {
+	return func() ([]byte, error) { return []byte(s), nil }
+}
+
+func TestSaveOpenRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "vault")
+
+	v := New(path)
+	v.Add("work", []byte("JBSWY3DPEHPK3PXP"))
+	v.Add("personal", []byte("GEZDGNBVGY3TQOJQ"))
+	if err := v.Save(passphraseOf("correct horse battery staple")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reopened, err := Open(path, passphraseOf("correct horse battery staple"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	secret, ok := reopened.Get("work")
+	if !ok || string(secret) != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("Get(%q) = %q, %v; want %q, true", "work", secret, ok, "JBSWY3DPEHPK3PXP")
+	}
+	if names := reopened.List(); len(names) != 2 || names[0] != "personal" || names[1] != "work" {
+		t.Fatalf("List() = %v; want [personal work]", names)
+	}
+}
+
+func TestOpenWrongPassphraseFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault")
+
+	v := New(path)
+	v.Add("work", []byte("JBSWY3DPEHPK3PXP"))
+	if err := v.Save(passphraseOf("right passphrase")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if _, err := Open(path, passphraseOf("wrong passphrase")); err == nil {
+		t.Fatal("Open with wrong passphrase succeeded; want an error")
+	}
+}
+
+func TestOpenTamperedFileFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault")
+
+	v := New(path)
+	v.Add("work", []byte("JBSWY3DPEHPK3PXP"))
+	if err := v.Save(passphraseOf("correct horse battery staple")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading vault file: %v", err)
+	}
+	raw[len(raw)-1] ^= 0xFF
+	if err := ioutil.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatalf("writing tampered vault file: %v", err)
+	}
+
+	if _, err := Open(path, passphraseOf("correct horse battery staple")); err == nil {
+		t.Fatal("Open with a tampered ciphertext succeeded; want an error")
+	}
+}